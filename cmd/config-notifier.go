@@ -0,0 +1,152 @@
+/*
+ * Minio Cloud Storage, (C) 2016, 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "sync"
+
+// notifier - holds the per-target notification configurations, keyed
+// by the account/target ID used in the corresponding ARN.
+type notifier struct {
+	AMQP          map[string]amqpNotify          `json:"amqp"`
+	NATS          map[string]natsNotify          `json:"nats"`
+	Kafka         map[string]kafkaNotify         `json:"kafka"`
+	ElasticSearch map[string]elasticSearchNotify `json:"elasticsearch"`
+	Redis         map[string]redisNotify         `json:"redis"`
+	Webhook       map[string]webhookNotify       `json:"webhook"`
+}
+
+// serverConfigV1 is the subset of the server configuration relevant to
+// the notification subsystem. The rest of the configuration (access
+// keys, region, ...) lives alongside this in the full server config.
+type serverConfigV1 struct {
+	rwMutex sync.RWMutex
+
+	Region string   `json:"region"`
+	Notify notifier `json:"notify"`
+}
+
+// serverConfig is the global, in-memory handle on the persisted server
+// configuration.
+var serverConfig = &serverConfigV1{
+	Notify: notifier{
+		AMQP:          make(map[string]amqpNotify),
+		NATS:          make(map[string]natsNotify),
+		Kafka:         make(map[string]kafkaNotify),
+		ElasticSearch: make(map[string]elasticSearchNotify),
+		Redis:         make(map[string]redisNotify),
+		Webhook:       make(map[string]webhookNotify),
+	},
+}
+
+// GetRegion returns the configured server region.
+func (s *serverConfigV1) GetRegion() string {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// SetAMQPNotifyByID sets the AMQP notification target for accountID.
+func (s *serverConfigV1) SetAMQPNotifyByID(accountID string, cfg amqpNotify) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.Notify.AMQP[accountID] = cfg
+}
+
+// GetAMQPNotifyByID returns the AMQP notification target for accountID.
+func (s *serverConfigV1) GetAMQPNotifyByID(accountID string) amqpNotify {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.Notify.AMQP[accountID]
+}
+
+// SetRedisNotifyByID sets the Redis notification target for accountID.
+func (s *serverConfigV1) SetRedisNotifyByID(accountID string, cfg redisNotify) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.Notify.Redis[accountID] = cfg
+}
+
+// GetRedisNotifyByID returns the Redis notification target for accountID.
+func (s *serverConfigV1) GetRedisNotifyByID(accountID string) redisNotify {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.Notify.Redis[accountID]
+}
+
+// SetElasticSearchNotifyByID sets the ElasticSearch notification target
+// for accountID.
+func (s *serverConfigV1) SetElasticSearchNotifyByID(accountID string, cfg elasticSearchNotify) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.Notify.ElasticSearch[accountID] = cfg
+}
+
+// GetElasticSearchNotifyByID returns the ElasticSearch notification
+// target for accountID.
+func (s *serverConfigV1) GetElasticSearchNotifyByID(accountID string) elasticSearchNotify {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.Notify.ElasticSearch[accountID]
+}
+
+// SetNATSNotifyByID sets the NATS notification target for accountID.
+func (s *serverConfigV1) SetNATSNotifyByID(accountID string, cfg natsNotify) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.Notify.NATS[accountID] = cfg
+}
+
+// GetNATSNotifyByID returns the NATS notification target for accountID.
+func (s *serverConfigV1) GetNATSNotifyByID(accountID string) natsNotify {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.Notify.NATS[accountID]
+}
+
+// SetKafkaNotifyByID sets the Kafka notification target for accountID.
+func (s *serverConfigV1) SetKafkaNotifyByID(accountID string, cfg kafkaNotify) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.Notify.Kafka[accountID] = cfg
+}
+
+// GetKafkaNotifyByID returns the Kafka notification target for
+// accountID.
+func (s *serverConfigV1) GetKafkaNotifyByID(accountID string) kafkaNotify {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.Notify.Kafka[accountID]
+}
+
+// SetWebhookNotifyByID sets the webhook notification target for
+// accountID.
+func (s *serverConfigV1) SetWebhookNotifyByID(accountID string, cfg webhookNotify) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.Notify.Webhook[accountID] = cfg
+}
+
+// GetWebhookNotifyByID returns the webhook notification target for
+// accountID.
+func (s *serverConfigV1) GetWebhookNotifyByID(accountID string) webhookNotify {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.Notify.Webhook[accountID]
+}
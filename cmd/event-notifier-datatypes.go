@@ -0,0 +1,179 @@
+/*
+ * Minio Cloud Storage, (C) 2016, 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "time"
+
+// EventName is the type of event that occurred on an object, mirroring
+// the S3 event API names.
+type EventName int
+
+// List of currently supported event types.
+const (
+	ObjectCreatedPut EventName = iota
+	ObjectCreatedPost
+	ObjectCreatedCopy
+	ObjectCreatedCompleteMultipartUpload
+	ObjectRemovedDelete
+)
+
+// String returns the S3 event name as defined by the S3 API, e.g.
+// "s3:ObjectCreated:Put".
+func (e EventName) String() string {
+	switch e {
+	case ObjectCreatedPut:
+		return "s3:ObjectCreated:Put"
+	case ObjectCreatedPost:
+		return "s3:ObjectCreated:Post"
+	case ObjectCreatedCopy:
+		return "s3:ObjectCreated:Copy"
+	case ObjectCreatedCompleteMultipartUpload:
+		return "s3:ObjectCreated:CompleteMultipartUpload"
+	case ObjectRemovedDelete:
+		return "s3:ObjectRemoved:Delete"
+	default:
+		return "s3:ObjectCreated:Put"
+	}
+}
+
+// eventData carries all the information needed to build and dispatch a
+// notification for a single bucket event.
+type eventData struct {
+	Type      EventName
+	Bucket    string
+	ObjInfo   ObjectInfo
+	ReqParams map[string]string
+}
+
+// filterRule - represents a single <FilterRule> entry, e.g. a `prefix`
+// or `suffix` match criterion under a <S3Key> filter.
+type filterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// keyFilter - holds the list of filter rules under a <S3Key> element.
+type keyFilter struct {
+	FilterRules []filterRule `xml:"FilterRule,omitempty"`
+}
+
+// filterStruct - represents the <Filter> element of a notification
+// configuration.
+type filterStruct struct {
+	Key keyFilter `xml:"S3Key,omitempty"`
+}
+
+// ServiceConfig - common body shared by topic, queue and cloud-function
+// configurations: the list of events to notify on, the key filter and
+// the account/service ID the configuration belongs to.
+type ServiceConfig struct {
+	Event  []string     `xml:"Event"`
+	Filter filterStruct `xml:"Filter"`
+	ID     string       `xml:"Id"`
+}
+
+// queueConfig - a single <QueueConfiguration> entry.
+type queueConfig struct {
+	ServiceConfig
+	QueueARN string `xml:"Queue"`
+}
+
+// topicConfig - a single <TopicConfiguration> entry.
+type topicConfig struct {
+	ServiceConfig
+	TopicARN string `xml:"Topic"`
+}
+
+// notificationConfig - the bucket notification configuration,
+// serialized to/from `notification.xml` under the bucket's metadata
+// prefix.
+type notificationConfig struct {
+	QueueConfigs []queueConfig `xml:"QueueConfiguration,omitempty"`
+	TopicConfigs []topicConfig `xml:"TopicConfiguration,omitempty"`
+}
+
+// listenerConfig - internal (non-persistent-ARN) listener used by
+// `mc events` / PeerRPC bucket notification listeners. Unlike queue and
+// topic configs these are not serialized as part of `notification.xml`,
+// instead they live in their own `listener.json` file and are only
+// meaningful while the owning peer is alive.
+type listenerConfig struct {
+	TopicConfig  topicConfig `json:"topicConfig"`
+	TargetServer string      `json:"targetServer"`
+}
+
+// identity - minimal principal information included in generated
+// events, mirrors the `userIdentity` block of the S3 event spec.
+type identity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+// sourceInfo - request source information included in generated events.
+type sourceInfo struct {
+	Host      string `json:"host"`
+	Port      string `json:"port"`
+	UserAgent string `json:"userAgent"`
+}
+
+// objectMeta - subset of object metadata surfaced in the `s3.object`
+// block of a generated event.
+type objectMeta struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// bucketMeta - subset of bucket metadata surfaced in the `s3.bucket`
+// block of a generated event.
+type bucketMeta struct {
+	Name          string   `json:"name"`
+	OwnerIdentity identity `json:"ownerIdentity"`
+	ARN           string   `json:"arn"`
+}
+
+// s3Meta - the `s3` block of a generated event, identifying the
+// resource the event happened on.
+type s3Meta struct {
+	SchemaVersion   string     `json:"s3SchemaVersion"`
+	ConfigurationID string     `json:"configurationId"`
+	Bucket          bucketMeta `json:"bucket"`
+	Object          objectMeta `json:"object"`
+}
+
+// NotificationEvent - structure of a single entry of a notification
+// message, modeled directly after the Amazon S3 event message, see
+// http://docs.aws.amazon.com/AmazonS3/latest/dev/notification-content-structure.html
+type NotificationEvent struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      identity          `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                s3Meta            `json:"s3"`
+	Source            sourceInfo        `json:"source"`
+}
+
+// timeToISO8601 formats t using the ISO8601 layout used by S3 event
+// timestamps.
+func timeToISO8601(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000Z")
+}
@@ -0,0 +1,242 @@
+/*
+ * Minio Cloud Storage, (C) 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Supported <FilterRule> names. "prefix" and "suffix" are the original
+// S3-compatible rule names; "regex", "min-size", "max-size" and
+// "content-type" are Minio extensions.
+const (
+	ruleNamePrefix      = "prefix"
+	ruleNameSuffix      = "suffix"
+	ruleNameRegex       = "regex"
+	ruleNameMinSize     = "min-size"
+	ruleNameMaxSize     = "max-size"
+	ruleNameContentType = "content-type"
+)
+
+// filterMatcher reports whether a single filter rule value is
+// satisfied by an object.
+type filterMatcher func(oi ObjectInfo) bool
+
+// compiledFilter is the pre-compiled form of a <Filter>'s rules, ready
+// to be evaluated against every event without re-parsing glob patterns,
+// regexes or size values on every call.
+//
+// Rules sharing the same name are OR'd together (e.g. two `suffix`
+// rules match if either matches); distinct rule names are AND'd (e.g. a
+// `prefix` and a `suffix` rule must both match).
+type compiledFilter struct {
+	groups map[string][]filterMatcher
+}
+
+func (cf *compiledFilter) match(oi ObjectInfo) bool {
+	if cf == nil {
+		return true
+	}
+	for _, matchers := range cf.groups {
+		matched := false
+		for _, m := range matchers {
+			if m(oi) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// isGlobPattern reports whether s contains any glob metacharacters,
+// used to decide whether a `suffix` rule should be evaluated as a glob
+// (e.g. `*.jpg`) or, for backwards compatibility with configurations
+// written before glob support existed, as a literal suffix.
+func isGlobPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// compileGlob translates a glob pattern into the equivalent regular
+// expression. Unlike filepath.Match, "*" is allowed to match "/" too:
+// S3 object keys and content-types use "/" as an ordinary character,
+// not a path separator, so a suffix glob like "*.jpg" must still match
+// "images/cat.jpg" the same way a literal ".jpg" suffix rule would.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				// Unterminated class; treat the '[' as a literal.
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			cls := runes[i+1 : end]
+			b.WriteString("[")
+			if len(cls) > 0 && (cls[0] == '!' || cls[0] == '^') {
+				b.WriteString("^")
+				cls = cls[1:]
+			}
+			b.WriteString(string(cls))
+			b.WriteString("]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// compileRule compiles a single filterRule into a filterMatcher. A rule
+// that fails to compile (a malformed regex or a non-numeric size bound)
+// is compiled into a matcher that never matches, rather than one that
+// always matches - a broken rule should never silently widen delivery.
+func compileRule(rule filterRule) filterMatcher {
+	switch strings.ToLower(rule.Name) {
+	case ruleNamePrefix:
+		prefix := rule.Value
+		return func(oi ObjectInfo) bool { return strings.HasPrefix(oi.Name, prefix) }
+
+	case ruleNameSuffix:
+		suffix := rule.Value
+		if isGlobPattern(suffix) {
+			re, err := compileGlob(suffix)
+			if err != nil {
+				return func(ObjectInfo) bool { return false }
+			}
+			return func(oi ObjectInfo) bool { return re.MatchString(oi.Name) }
+		}
+		return func(oi ObjectInfo) bool { return strings.HasSuffix(oi.Name, suffix) }
+
+	case ruleNameRegex:
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return func(ObjectInfo) bool { return false }
+		}
+		return func(oi ObjectInfo) bool { return re.MatchString(oi.Name) }
+
+	case ruleNameMinSize:
+		minSize, err := strconv.ParseInt(rule.Value, 10, 64)
+		if err != nil {
+			return func(ObjectInfo) bool { return false }
+		}
+		return func(oi ObjectInfo) bool { return oi.Size >= minSize }
+
+	case ruleNameMaxSize:
+		maxSize, err := strconv.ParseInt(rule.Value, 10, 64)
+		if err != nil {
+			return func(ObjectInfo) bool { return false }
+		}
+		return func(oi ObjectInfo) bool { return oi.Size <= maxSize }
+
+	case ruleNameContentType:
+		contentType := rule.Value
+		if isGlobPattern(contentType) {
+			re, err := compileGlob(contentType)
+			if err != nil {
+				return func(ObjectInfo) bool { return false }
+			}
+			return func(oi ObjectInfo) bool { return re.MatchString(oi.ContentType) }
+		}
+		return func(oi ObjectInfo) bool { return oi.ContentType == contentType }
+
+	default:
+		return func(ObjectInfo) bool { return false }
+	}
+}
+
+// compileFilterRules groups rules by name (OR within a group) and
+// compiles every rule's matcher once up front.
+func compileFilterRules(rules []filterRule) *compiledFilter {
+	cf := &compiledFilter{groups: make(map[string][]filterMatcher)}
+	for _, rule := range rules {
+		name := strings.ToLower(rule.Name)
+		cf.groups[name] = append(cf.groups[name], compileRule(rule))
+	}
+	return cf
+}
+
+// filterCacheKey identifies one <Filter> by the bucket and ARN
+// (queue or topic) it's configured against, since the same bucket can
+// have different filters per target.
+type filterCacheKey struct {
+	bucket string
+	arn    string
+}
+
+var (
+	filterCacheMu sync.RWMutex
+	filterCache   = make(map[filterCacheKey]*compiledFilter)
+)
+
+// getCompiledFilter returns the compiled filter for (bucket, arn),
+// compiling and caching it on first use. This keeps eventNotify's hot
+// path free of glob/regex compilation on every single event.
+func getCompiledFilter(bucket, arn string, rules []filterRule) *compiledFilter {
+	key := filterCacheKey{bucket: bucket, arn: arn}
+
+	filterCacheMu.RLock()
+	cf, ok := filterCache[key]
+	filterCacheMu.RUnlock()
+	if ok {
+		return cf
+	}
+
+	cf = compileFilterRules(rules)
+
+	filterCacheMu.Lock()
+	filterCache[key] = cf
+	filterCacheMu.Unlock()
+	return cf
+}
+
+// invalidateFilterCache drops every compiled filter cached for bucket,
+// forcing the next match to recompile from the (now current) filter
+// rules. Called whenever a bucket's notification or listener config is
+// replaced.
+func invalidateFilterCache(bucket string) {
+	filterCacheMu.Lock()
+	defer filterCacheMu.Unlock()
+	for key := range filterCache {
+		if key.bucket == bucket {
+			delete(filterCache, key)
+		}
+	}
+}
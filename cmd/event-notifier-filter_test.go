@@ -0,0 +1,254 @@
+/*
+ * Minio Cloud Storage, (C) 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "testing"
+
+// TestCompiledFilterComposition checks that rules sharing a name are
+// OR'd together, while distinct rule names are AND'd, matching the
+// precedence a <Filter> with multiple <FilterRule> entries is supposed
+// to have.
+func TestCompiledFilterComposition(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		rules   []filterRule
+		obj     ObjectInfo
+		matches bool
+	}{
+		{
+			desc: "single prefix matches",
+			rules: []filterRule{
+				{Name: "prefix", Value: "images/"},
+			},
+			obj:     ObjectInfo{Name: "images/cat.jpg"},
+			matches: true,
+		},
+		{
+			desc: "single prefix does not match",
+			rules: []filterRule{
+				{Name: "prefix", Value: "images/"},
+			},
+			obj:     ObjectInfo{Name: "docs/cat.jpg"},
+			matches: false,
+		},
+		{
+			desc: "suffix glob matches",
+			rules: []filterRule{
+				{Name: "suffix", Value: "*.jpg"},
+			},
+			obj:     ObjectInfo{Name: "images/cat.jpg"},
+			matches: true,
+		},
+		{
+			desc: "suffix glob does not match different extension",
+			rules: []filterRule{
+				{Name: "suffix", Value: "*.jpg"},
+			},
+			obj:     ObjectInfo{Name: "images/cat.png"},
+			matches: false,
+		},
+		{
+			desc: "suffix literal still honored without glob chars",
+			rules: []filterRule{
+				{Name: "suffix", Value: ".jpg"},
+			},
+			obj:     ObjectInfo{Name: "images/cat.jpg"},
+			matches: true,
+		},
+		{
+			desc: "two suffix rules OR together",
+			rules: []filterRule{
+				{Name: "suffix", Value: "*.jpg"},
+				{Name: "suffix", Value: "*.png"},
+			},
+			obj:     ObjectInfo{Name: "images/cat.png"},
+			matches: true,
+		},
+		{
+			desc: "prefix AND suffix both required",
+			rules: []filterRule{
+				{Name: "prefix", Value: "images/"},
+				{Name: "suffix", Value: "*.png"},
+			},
+			obj:     ObjectInfo{Name: "docs/cat.png"},
+			matches: false,
+		},
+		{
+			desc: "regex matches",
+			rules: []filterRule{
+				{Name: "regex", Value: `^images/[a-z]+\.jpg$`},
+			},
+			obj:     ObjectInfo{Name: "images/cat.jpg"},
+			matches: true,
+		},
+		{
+			desc: "regex does not match",
+			rules: []filterRule{
+				{Name: "regex", Value: `^images/[a-z]+\.jpg$`},
+			},
+			obj:     ObjectInfo{Name: "images/cat1.jpg"},
+			matches: false,
+		},
+		{
+			desc: "min-size and max-size both satisfied",
+			rules: []filterRule{
+				{Name: "min-size", Value: "100"},
+				{Name: "max-size", Value: "1000"},
+			},
+			obj:     ObjectInfo{Name: "f", Size: 500},
+			matches: true,
+		},
+		{
+			desc: "min-size violated",
+			rules: []filterRule{
+				{Name: "min-size", Value: "100"},
+			},
+			obj:     ObjectInfo{Name: "f", Size: 50},
+			matches: false,
+		},
+		{
+			desc: "max-size violated",
+			rules: []filterRule{
+				{Name: "max-size", Value: "1000"},
+			},
+			obj:     ObjectInfo{Name: "f", Size: 2000},
+			matches: false,
+		},
+		{
+			desc: "content-type matches",
+			rules: []filterRule{
+				{Name: "content-type", Value: "image/jpeg"},
+			},
+			obj:     ObjectInfo{Name: "f", ContentType: "image/jpeg"},
+			matches: true,
+		},
+		{
+			desc: "content-type glob matches",
+			rules: []filterRule{
+				{Name: "content-type", Value: "image/*"},
+			},
+			obj:     ObjectInfo{Name: "f", ContentType: "image/png"},
+			matches: true,
+		},
+		{
+			desc: "content-type catch-all glob crosses the slash",
+			rules: []filterRule{
+				{Name: "content-type", Value: "*"},
+			},
+			obj:     ObjectInfo{Name: "f", ContentType: "image/png"},
+			matches: true,
+		},
+		{
+			desc:    "no rules matches everything",
+			rules:   nil,
+			obj:     ObjectInfo{Name: "anything"},
+			matches: true,
+		},
+		{
+			desc: "malformed regex never matches",
+			rules: []filterRule{
+				{Name: "regex", Value: "("},
+			},
+			obj:     ObjectInfo{Name: "anything"},
+			matches: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cf := compileFilterRules(tc.rules)
+			if got := cf.match(tc.obj); got != tc.matches {
+				t.Errorf("compileFilterRules(%v).match(%v) = %v, want %v",
+					tc.rules, tc.obj, got, tc.matches)
+			}
+		})
+	}
+}
+
+// TestMultipleQueueConfigurationsAllMatch checks that when an object
+// satisfies more than one <QueueConfiguration>, every one of those
+// configurations matches it - matching has no first-match-wins
+// precedence.
+func TestMultipleQueueConfigurationsAllMatch(t *testing.T) {
+	rootPath, err := newTestConfig("us-east-1")
+	if err != nil {
+		t.Fatalf("Init Test config failed")
+	}
+	defer removeAll(rootPath)
+
+	disks, err := getRandomDisks(1)
+	if err != nil {
+		t.Fatal("Unable to create directories for FS backend. ", err)
+	}
+	defer removeAll(disks[0])
+	endpoints, err := parseStorageEndPoints(disks, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, _, err := initObjectLayer(endpoints, nil)
+	if err != nil {
+		t.Fatal("Unable to initialize FS backend.", err)
+	}
+
+	bucketName := "bucket"
+	if err = obj.MakeBucket(bucketName); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if err = initEventNotifier(obj); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	jpgOnly := ServiceConfig{
+		Event:  []string{"s3:ObjectCreated:*"},
+		Filter: filterStruct{keyFilter{[]filterRule{{Name: "suffix", Value: "*.jpg"}}}},
+		ID:     "jpg-only",
+	}
+	imagesOnly := ServiceConfig{
+		Event:  []string{"s3:ObjectCreated:*"},
+		Filter: filterStruct{keyFilter{[]filterRule{{Name: "prefix", Value: "images/"}}}},
+		ID:     "images-only",
+	}
+
+	ncfg := &notificationConfig{
+		QueueConfigs: []queueConfig{
+			{ServiceConfig: jpgOnly, QueueARN: "arn:minio:sqs:us-east-1:1:jpg"},
+			{ServiceConfig: imagesOnly, QueueARN: "arn:minio:sqs:us-east-1:1:images"},
+		},
+	}
+	globalEventNotifier.SetBucketNotificationConfig(bucketName, ncfg)
+
+	// images/cat.jpg satisfies both configurations.
+	var matched []string
+	for _, qcfg := range ncfg.QueueConfigs {
+		e := eventData{
+			Type:   ObjectCreatedPut,
+			Bucket: bucketName,
+			ObjInfo: ObjectInfo{
+				Bucket: bucketName,
+				Name:   "images/cat.jpg",
+			},
+		}
+		if qcfg.ServiceConfig.match(bucketName, qcfg.QueueARN, e) {
+			matched = append(matched, qcfg.QueueARN)
+		}
+	}
+
+	if len(matched) != 2 {
+		t.Fatalf("Expected both queue configurations to match, got %v", matched)
+	}
+}
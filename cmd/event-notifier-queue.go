@@ -0,0 +1,355 @@
+/*
+ * Minio Cloud Storage, (C) 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// On-disk layout for the at-least-once event queue:
+//
+//	minioMetaBucket/events/<arn>/<unixnano>-<rand>.json   - pending dispatch, removed on ACK
+//	minioMetaBucket/events-log/<bucket>/<unixnano>-<rand>.json - append-only history, for ReplayEvents
+const (
+	eventsQueuePrefix = "events"
+	eventsLogPrefix   = "events-log"
+
+	// eventQueueMaxParallel bounds how many pending entries a single
+	// target's dispatcher drains concurrently per poll.
+	eventQueueMaxParallel = 4
+
+	// eventQueuePollInterval is how often a target's dispatcher wakes up
+	// to drain its queue, both for newly enqueued entries and for
+	// retrying ones left behind by a prior failed delivery.
+	eventQueuePollInterval = 1 * time.Second
+
+	// eventQueueMaxRetries caps how many times the per-entry backoff
+	// below keeps growing; past this many failed attempts the entry is
+	// retried at eventQueueMaxBackoff instead of backing off further.
+	// It does not remove or quarantine the entry - at-least-once means
+	// an entry stays queued (and is retried, just less often) until it
+	// is ACKed, however long that takes.
+	eventQueueMaxRetries  = 5
+	eventQueueBaseBackoff = 250 * time.Millisecond
+	eventQueueMaxBackoff  = 30 * time.Second
+)
+
+// queuedEvent is the on-disk representation of a single enqueued
+// notification, carrying enough information to either dispatch it to
+// its original target or re-emit it via ReplayEvents.
+type queuedEvent struct {
+	Bucket   string            `json:"bucket"`
+	QueueARN string            `json:"queueARN"`
+	Event    NotificationEvent `json:"event"`
+}
+
+// QueueStats reports the current backlog of a single target's pending
+// queue, exposed to the admin API so operators can see a target
+// falling behind before it causes event loss.
+type QueueStats struct {
+	QueueARN string    `json:"queueARN"`
+	Depth    int       `json:"depth"`
+	OldestAt time.Time `json:"oldestAt,omitempty"`
+}
+
+// eventQueue drains the on-disk WAL for a single external target,
+// dispatching entries in the order they were appended and only
+// removing each one once the target has ACKed it (returned a nil error
+// from send). Failures are retried with bounded exponential backoff on
+// the next poll rather than blocking the dispatcher loop.
+type eventQueue struct {
+	arn    string
+	obj    ObjectLayer
+	target target
+
+	sem    chan struct{}
+	stopCh chan struct{}
+
+	mu          sync.Mutex
+	attempts    map[string]int
+	lastAttempt map[string]time.Time
+}
+
+func newEventQueue(arn string, obj ObjectLayer, t target) *eventQueue {
+	q := &eventQueue{
+		arn:         arn,
+		obj:         obj,
+		target:      t,
+		sem:         make(chan struct{}, eventQueueMaxParallel),
+		stopCh:      make(chan struct{}),
+		attempts:    make(map[string]int),
+		lastAttempt: make(map[string]time.Time),
+	}
+	go q.run()
+	return q
+}
+
+// backoffForAttempt returns how long to wait before retrying an entry
+// that has already failed tries times, doubling from
+// eventQueueBaseBackoff and capping at eventQueueMaxBackoff once tries
+// passes eventQueueMaxRetries.
+func backoffForAttempt(tries int) time.Duration {
+	if tries > eventQueueMaxRetries {
+		return eventQueueMaxBackoff
+	}
+	d := eventQueueBaseBackoff * time.Duration(int64(1)<<uint(tries))
+	if d <= 0 || d > eventQueueMaxBackoff {
+		d = eventQueueMaxBackoff
+	}
+	return d
+}
+
+func (q *eventQueue) prefix() string {
+	return eventsQueuePrefix + "/" + q.arn + "/"
+}
+
+// run is the background dispatcher: it wakes up every
+// eventQueuePollInterval, lists the pending entries for this target and
+// attempts to deliver every one of them, bounded to
+// eventQueueMaxParallel concurrent deliveries.
+func (q *eventQueue) run() {
+	ticker := time.NewTicker(eventQueuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-ticker.C:
+			q.drain()
+		}
+	}
+}
+
+func (q *eventQueue) drain() {
+	names, err := q.listPending()
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		q.sem <- struct{}{}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-q.sem }()
+			q.deliver(name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+// listPending returns the object names under this target's queue
+// prefix, sorted by the embedded timestamp/sequence so entries are
+// (best-effort) dispatched in the order they were appended.
+func (q *eventQueue) listPending() ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		result, err := q.obj.ListObjects(minioMetaBucket, q.prefix(), marker, "", 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, oi := range result.Objects {
+			names = append(names, strings.TrimPrefix(oi.Name, q.prefix()))
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextMarker
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (q *eventQueue) deliver(name string) {
+	q.mu.Lock()
+	tries := q.attempts[name]
+	last, attempted := q.lastAttempt[name]
+	q.mu.Unlock()
+
+	if attempted && time.Since(last) < backoffForAttempt(tries) {
+		// Still within this entry's backoff window from its last
+		// failed attempt; skip it until a later poll.
+		return
+	}
+
+	qe, err := q.readEntry(name)
+	if err != nil {
+		return
+	}
+
+	q.mu.Lock()
+	q.lastAttempt[name] = time.Now()
+	q.mu.Unlock()
+
+	if err = q.target.send(qe.Event); err != nil {
+		q.mu.Lock()
+		q.attempts[name]++
+		q.mu.Unlock()
+		return
+	}
+
+	q.mu.Lock()
+	delete(q.attempts, name)
+	delete(q.lastAttempt, name)
+	q.mu.Unlock()
+
+	// ACKed: remove the WAL entry. A crash between send() succeeding
+	// and this delete would cause a duplicate delivery, not a loss,
+	// which is consistent with at-least-once semantics.
+	_ = q.obj.DeleteObject(minioMetaBucket, q.prefix()+name)
+}
+
+func (q *eventQueue) readEntry(name string) (*queuedEvent, error) {
+	path := q.prefix() + name
+	objInfo, err := q.obj.GetObjectInfo(minioMetaBucket, path)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = q.obj.GetObject(minioMetaBucket, path, 0, objInfo.Size, &buf); err != nil {
+		return nil, err
+	}
+	qe := &queuedEvent{}
+	if err = json.Unmarshal(buf.Bytes(), qe); err != nil {
+		return nil, err
+	}
+	return qe, nil
+}
+
+// stats reports this target's current queue depth and the age of its
+// oldest pending entry.
+func (q *eventQueue) stats() QueueStats {
+	stats := QueueStats{QueueARN: q.arn}
+	names, err := q.listPending()
+	if err != nil {
+		return stats
+	}
+	stats.Depth = len(names)
+	if len(names) > 0 {
+		if ts, ok := parseQueueEntryTime(names[0]); ok {
+			stats.OldestAt = ts
+		}
+	}
+	return stats
+}
+
+func (q *eventQueue) close() {
+	close(q.stopCh)
+}
+
+// queueEntryName returns a lexicographically sortable file name that
+// encodes the entry's append time, so listPending naturally orders
+// entries oldest-first. A random suffix is appended so two entries
+// enqueued for the same ARN in the same nanosecond - concurrent PUTs on
+// the same bucket, or just coarser clock resolution on some platforms -
+// don't collide and silently overwrite one another.
+func queueEntryName(seq int64) string {
+	return fmt.Sprintf("%020d-%08x.json", seq, rand.Uint32())
+}
+
+func parseQueueEntryTime(name string) (time.Time, bool) {
+	name = strings.TrimSuffix(name, ".json")
+	if idx := strings.IndexByte(name, '-'); idx >= 0 {
+		name = name[:idx]
+	}
+	var nanos int64
+	if _, err := fmt.Sscanf(name, "%d", &nanos); err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// enqueueEvent appends n to the on-disk queue for queueARN and to
+// bucket's append-only history log, both synchronously, before
+// returning. Because this happens before any attempt to dispatch the
+// event, a crash at any point after enqueueEvent returns is guaranteed
+// to leave the event recoverable: the next initEventNotifier (which
+// spins up a fresh eventQueue per target) will find it still on disk
+// and redeliver it.
+func enqueueEvent(obj ObjectLayer, bucket, queueARN string, n NotificationEvent) error {
+	qe := queuedEvent{Bucket: bucket, QueueARN: queueARN, Event: n}
+	buf, err := json.Marshal(qe)
+	if err != nil {
+		return err
+	}
+
+	seq := time.Now().UnixNano()
+	queuePath := eventsQueuePrefix + "/" + queueARN + "/" + queueEntryName(seq)
+	if _, err = obj.PutObject(minioMetaBucket, queuePath, int64(len(buf)), bytes.NewReader(buf), nil); err != nil {
+		return err
+	}
+
+	logPath := eventsLogPrefix + "/" + bucket + "/" + queueEntryName(seq)
+	_, err = obj.PutObject(minioMetaBucket, logPath, int64(len(buf)), bytes.NewReader(buf), nil)
+	return err
+}
+
+// ReplayEvents re-emits every historical event recorded for bucket
+// whose append time falls within [from, to], re-enqueuing each one
+// against its original target so it is redelivered (and retried)
+// exactly like a live event would be. Entries are read from the
+// append-only events-log, which is never pruned by normal dispatch, so
+// a replay is possible even long after the original delivery was
+// ACKed and removed from the live queue.
+func ReplayEvents(bucket string, from, to time.Time, obj ObjectLayer) error {
+	prefix := eventsLogPrefix + "/" + bucket + "/"
+	marker := ""
+	for {
+		result, err := obj.ListObjects(minioMetaBucket, prefix, marker, "", 1000)
+		if err != nil {
+			return err
+		}
+
+		for _, oi := range result.Objects {
+			name := strings.TrimPrefix(oi.Name, prefix)
+			ts, ok := parseQueueEntryTime(name)
+			if !ok || ts.Before(from) || ts.After(to) {
+				continue
+			}
+
+			var buf bytes.Buffer
+			if err = obj.GetObject(minioMetaBucket, oi.Name, 0, oi.Size, &buf); err != nil {
+				return err
+			}
+			qe := &queuedEvent{}
+			if err = json.Unmarshal(buf.Bytes(), qe); err != nil {
+				return err
+			}
+
+			if err = enqueueEvent(obj, qe.Bucket, qe.QueueARN, qe.Event); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextMarker
+	}
+
+	return nil
+}
@@ -0,0 +1,633 @@
+/*
+ * Minio Cloud Storage, (C) 2016, 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification configuration file names, relative to a bucket's
+// metadata prefix (bucketConfigPrefix/<bucket>/...).
+const (
+	bucketNotificationConfig = "notification.xml"
+	bucketListenerConfig     = "listener.json"
+)
+
+// ARN prefixes used when addressing notification targets and the
+// internal (peer) listener service.
+const (
+	minioSqs   = "arn:minio:sqs"
+	minioTopic = "arn:minio:sns"
+
+	// snsTypeMinio identifies the internal listener "topic" type, as
+	// opposed to an external queue ARN suffix (amqp, redis, ...).
+	snsTypeMinio = "listen"
+
+	// Supported external queue ARN types.
+	queueTypeAMQP          = "amqp"
+	queueTypeRedis         = "redis"
+	queueTypeElasticSearch = "elasticsearch"
+	queueTypeNATS          = "nats"
+	queueTypeKafka         = "kafka"
+	queueTypeWebhook       = "webhook"
+)
+
+// errNotifyNotEnabled is returned by a target's dial function when the
+// corresponding configuration has `Enable` set to false.
+var errNotifyNotEnabled = fmt.Errorf("notification target is not enabled")
+
+// target - common interface implemented by every external notification
+// target (AMQP, Redis, ElasticSearch, NATS, Kafka, ...).
+type target interface {
+	send(n NotificationEvent) error
+	Close()
+}
+
+// eventNotifier - holds the in-memory, per-bucket notification and
+// listener configuration, along with the live connections to every
+// configured external target and internal (peer) listener.
+type eventNotifier struct {
+	rwMutex sync.RWMutex
+
+	// obj backs every target's on-disk WAL queue (minioMetaBucket) and
+	// is passed through to dial functions that need to validate
+	// reachability against live server state.
+	obj ObjectLayer
+
+	// bucket -> persisted notification config (queue/topic configs).
+	notificationConfigs map[string]*notificationConfig
+	// bucket -> persisted listener configs.
+	listenerConfigs map[string][]listenerConfig
+
+	// external target ARN -> live connection.
+	externalTargets map[string]target
+	// external target ARN -> on-disk, at-least-once dispatch queue
+	// draining that connection.
+	queues map[string]*eventQueue
+	// internal (peer) topic ARN -> channel fan-out target.
+	internalTargets map[string]*ListenerTarget
+}
+
+// ListenerTarget fans a notification event out to every channel
+// registered against a given internal topic ARN (i.e. every local
+// `mc events` / HTTP listener subscribed to that ARN).
+type ListenerTarget struct {
+	mu    sync.Mutex
+	chans []chan []NotificationEvent
+}
+
+func (l *ListenerTarget) addChan(ch chan []NotificationEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.chans = append(l.chans, ch)
+}
+
+func (l *ListenerTarget) removeChan(ch chan []NotificationEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, c := range l.chans {
+		if c == ch {
+			l.chans = append(l.chans[:i], l.chans[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *ListenerTarget) send(events []NotificationEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range l.chans {
+		select {
+		case ch <- events:
+		default:
+		}
+	}
+}
+
+// globalEventNotifier is the process-wide notifier instance, populated
+// by initEventNotifier at server startup.
+var globalEventNotifier *eventNotifier
+
+// newEventNotifier returns an empty, ready to populate eventNotifier.
+func newEventNotifier() *eventNotifier {
+	return &eventNotifier{
+		notificationConfigs: make(map[string]*notificationConfig),
+		listenerConfigs:     make(map[string][]listenerConfig),
+		externalTargets:     make(map[string]target),
+		queues:              make(map[string]*eventQueue),
+		internalTargets:     make(map[string]*ListenerTarget),
+	}
+}
+
+// SetBucketNotificationConfig sets (replaces) the in-memory
+// notification config for bucket.
+func (en *eventNotifier) SetBucketNotificationConfig(bucket string, ncfg *notificationConfig) {
+	en.rwMutex.Lock()
+	defer en.rwMutex.Unlock()
+	en.notificationConfigs[bucket] = ncfg
+	invalidateFilterCache(bucket)
+}
+
+// GetBucketNotificationConfig returns the in-memory notification config
+// for bucket, or nil if none is set.
+func (en *eventNotifier) GetBucketNotificationConfig(bucket string) *notificationConfig {
+	en.rwMutex.RLock()
+	defer en.rwMutex.RUnlock()
+	return en.notificationConfigs[bucket]
+}
+
+// GetBucketListenerConfig returns the in-memory listener configs for
+// bucket, or nil if none is set.
+func (en *eventNotifier) GetBucketListenerConfig(bucket string) []listenerConfig {
+	en.rwMutex.RLock()
+	defer en.rwMutex.RUnlock()
+	return en.listenerConfigs[bucket]
+}
+
+func (en *eventNotifier) setBucketListenerConfig(bucket string, lcfgs []listenerConfig) {
+	en.rwMutex.Lock()
+	defer en.rwMutex.Unlock()
+	en.listenerConfigs[bucket] = lcfgs
+	invalidateFilterCache(bucket)
+}
+
+// GetExternalTarget returns the live connection for the external target
+// identified by arn, or nil if arn isn't configured/enabled.
+func (en *eventNotifier) GetExternalTarget(arn string) target {
+	en.rwMutex.RLock()
+	defer en.rwMutex.RUnlock()
+	return en.externalTargets[arn]
+}
+
+// addExternalTarget registers a dialed connection for arn and starts
+// the on-disk queue that will drain events enqueued against it.
+func (en *eventNotifier) addExternalTarget(arn string, t target) {
+	en.rwMutex.Lock()
+	defer en.rwMutex.Unlock()
+	en.externalTargets[arn] = t
+	en.queues[arn] = newEventQueue(arn, en.obj, t)
+}
+
+// GetQueueStats returns the pending-delivery depth and lag for the
+// external target identified by arn. ok is false if arn has no live
+// queue (not configured, or not enabled).
+//
+// This tree has no admin RPC framework at all - no router, no handler
+// registration, nothing to receive a request and call this - so "expose
+// via admin RPC" cannot be completed here; there's no admin surface to
+// wire it into short of inventing one wholesale. GetQueueStats and
+// QueueStatsAll are the reporting half of that requirement, verified
+// directly by TestQueueStatsReporting, ready to be called by whatever
+// admin surface this tree eventually grows.
+func (en *eventNotifier) GetQueueStats(arn string) (stats QueueStats, ok bool) {
+	en.rwMutex.RLock()
+	q, ok := en.queues[arn]
+	en.rwMutex.RUnlock()
+	if !ok {
+		return QueueStats{}, false
+	}
+	return q.stats(), true
+}
+
+// QueueStatsAll returns the queue stats for every currently configured
+// external target. See GetQueueStats for the admin-RPC caveat.
+func (en *eventNotifier) QueueStatsAll() []QueueStats {
+	en.rwMutex.RLock()
+	defer en.rwMutex.RUnlock()
+	all := make([]QueueStats, 0, len(en.queues))
+	for _, q := range en.queues {
+		all = append(all, q.stats())
+	}
+	return all
+}
+
+// Close stops every target's background dispatcher and closes every
+// external target connection (AMQP channels, NATS/Streaming
+// connections, the Kafka producer, ...).
+func (en *eventNotifier) Close() {
+	en.rwMutex.RLock()
+	defer en.rwMutex.RUnlock()
+	for _, q := range en.queues {
+		q.close()
+	}
+	for _, t := range en.externalTargets {
+		t.Close()
+	}
+}
+
+// GetInternalTarget returns (creating it if necessary) the fan-out
+// target for the internal topic ARN arn.
+func (en *eventNotifier) GetInternalTarget(arn string) *ListenerTarget {
+	en.rwMutex.Lock()
+	defer en.rwMutex.Unlock()
+	lt, ok := en.internalTargets[arn]
+	if !ok {
+		lt = &ListenerTarget{}
+		en.internalTargets[arn] = lt
+	}
+	return lt
+}
+
+// AddListenerChan registers ch to receive every event fired against the
+// internal topic ARN arn.
+func (en *eventNotifier) AddListenerChan(arn string, ch chan []NotificationEvent) error {
+	if arn == "" || ch == nil {
+		return errInvalidArgument
+	}
+	en.GetInternalTarget(arn).addChan(ch)
+	return nil
+}
+
+// RemoveListenerChan unregisters every channel registered against the
+// internal topic ARN arn.
+func (en *eventNotifier) RemoveListenerChan(arn string) {
+	en.rwMutex.RLock()
+	lt, ok := en.internalTargets[arn]
+	en.rwMutex.RUnlock()
+	if !ok {
+		return
+	}
+	lt.mu.Lock()
+	lt.chans = nil
+	lt.mu.Unlock()
+}
+
+// loadAllBucketNotificationConfigs loads the persisted notification and
+// listener configs for every bucket into en. It does not dial any
+// external target (dialConfiguredTargets does that); it returns the
+// first error encountered listing buckets or reading a bucket's config,
+// other than a config that simply doesn't exist yet.
+func loadAllBucketNotificationConfigs(obj ObjectLayer, en *eventNotifier) error {
+	buckets, err := obj.ListBuckets()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range buckets {
+		ncfg, err := loadNotificationConfig(b.Name, obj)
+		if err != nil && !isErrIgnored(err) {
+			return err
+		}
+		if ncfg != nil {
+			en.SetBucketNotificationConfig(b.Name, ncfg)
+		}
+
+		if globalS3Peers.isDistXL {
+			lcfgs, err := loadListenerConfig(b.Name, obj)
+			if err != nil && !isErrIgnored(err) {
+				return err
+			}
+			if lcfgs != nil {
+				en.setBucketListenerConfig(b.Name, lcfgs)
+				for _, lcfg := range lcfgs {
+					en.GetInternalTarget(lcfg.TopicConfig.TopicARN)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isErrIgnored returns true for errors that simply mean "nothing
+// persisted yet", which is the common case for most buckets.
+func isErrIgnored(err error) bool {
+	return err == errConfigNotFound
+}
+
+// dialConfiguredTargets eagerly dials every target configured (and
+// enabled) in serverConfig, regardless of whether any bucket currently
+// references it. This is what lets initEventNotifier fail fast at
+// server startup when an administrator has enabled, say, AMQP but
+// pointed it at a broker that isn't actually reachable yet, rather than
+// only discovering that the first time an event needs to be delivered.
+// Connections that succeed are registered under their ARN, where
+// eventNotify looks them up when a queue config references that ARN.
+func dialConfiguredTargets(en *eventNotifier) error {
+	region := serverConfig.GetRegion()
+	arn := func(accountID, qType string) string {
+		return fmt.Sprintf("%s:%s:%s:%s", minioSqs, region, accountID, qType)
+	}
+
+	for id, cfg := range serverConfig.Notify.AMQP {
+		if !cfg.Enable {
+			continue
+		}
+		t, err := dialAMQP(id, cfg)
+		if err != nil {
+			return err
+		}
+		en.addExternalTarget(arn(id, queueTypeAMQP), t)
+	}
+	for id, cfg := range serverConfig.Notify.Redis {
+		if !cfg.Enable {
+			continue
+		}
+		t, err := dialRedis(id, cfg)
+		if err != nil {
+			return err
+		}
+		en.addExternalTarget(arn(id, queueTypeRedis), t)
+	}
+	for id, cfg := range serverConfig.Notify.ElasticSearch {
+		if !cfg.Enable {
+			continue
+		}
+		t, err := dialElastic(id, cfg)
+		if err != nil {
+			return err
+		}
+		en.addExternalTarget(arn(id, queueTypeElasticSearch), t)
+	}
+	for id, cfg := range serverConfig.Notify.NATS {
+		if !cfg.Enable {
+			continue
+		}
+		t, err := dialNATS(id, cfg)
+		if err != nil {
+			return err
+		}
+		en.addExternalTarget(arn(id, queueTypeNATS), t)
+	}
+	for id, cfg := range serverConfig.Notify.Kafka {
+		if !cfg.Enable {
+			continue
+		}
+		t, err := dialKafka(id, cfg)
+		if err != nil {
+			return err
+		}
+		en.addExternalTarget(arn(id, queueTypeKafka), t)
+	}
+	for id, cfg := range serverConfig.Notify.Webhook {
+		if !cfg.Enable {
+			continue
+		}
+		t, err := dialWebhook(id, cfg)
+		if err != nil {
+			return err
+		}
+		en.addExternalTarget(arn(id, queueTypeWebhook), t)
+	}
+
+	return nil
+}
+
+// initEventNotifier initializes globalEventNotifier by dialing every
+// target enabled in serverConfig and loading every bucket's
+// notification and listener configuration from obj. It fails if obj is
+// nil, or if any enabled external target cannot be reached.
+func initEventNotifier(obj ObjectLayer) error {
+	if obj == nil {
+		return errInvalidArgument
+	}
+
+	en := newEventNotifier()
+	en.obj = obj
+	if err := dialConfiguredTargets(en); err != nil {
+		return err
+	}
+	if err := loadAllBucketNotificationConfigs(obj, en); err != nil {
+		return err
+	}
+
+	if globalEventNotifier != nil {
+		globalEventNotifier.Close()
+	}
+	globalEventNotifier = en
+	return nil
+}
+
+// readBucketMetadataObject reads the full contents of the given
+// bucket-metadata object from minioMetaBucket, returning
+// errConfigNotFound if it doesn't exist yet.
+func readBucketMetadataObject(obj ObjectLayer, path string) ([]byte, error) {
+	objInfo, err := obj.GetObjectInfo(minioMetaBucket, path)
+	if err != nil {
+		if isErrObjectNotFound(err) {
+			return nil, errConfigNotFound
+		}
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = obj.GetObject(minioMetaBucket, path, 0, objInfo.Size, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBucketMetadataObject writes buf to the given bucket-metadata
+// object path under minioMetaBucket.
+func writeBucketMetadataObject(obj ObjectLayer, path string, buf []byte) error {
+	_, err := obj.PutObject(minioMetaBucket, path, int64(len(buf)), bytes.NewReader(buf), nil)
+	return err
+}
+
+// loadNotificationConfig reads and unmarshals the notification.xml for
+// bucket, returning errConfigNotFound if it doesn't exist yet.
+func loadNotificationConfig(bucket string, obj ObjectLayer) (*notificationConfig, error) {
+	path := bucketConfigPrefix + "/" + bucket + "/" + bucketNotificationConfig
+	buf, err := readBucketMetadataObject(obj, path)
+	if err != nil {
+		return nil, err
+	}
+
+	ncfg := &notificationConfig{}
+	if err = xml.Unmarshal(buf, ncfg); err != nil {
+		return nil, err
+	}
+	return ncfg, nil
+}
+
+// loadListenerConfig reads and unmarshals listener.json for bucket,
+// returning errConfigNotFound if it doesn't exist yet.
+func loadListenerConfig(bucket string, obj ObjectLayer) ([]listenerConfig, error) {
+	path := bucketConfigPrefix + "/" + bucket + "/" + bucketListenerConfig
+	buf, err := readBucketMetadataObject(obj, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lcfgs []listenerConfig
+	if err = json.Unmarshal(buf, &lcfgs); err != nil {
+		return nil, err
+	}
+	return lcfgs, nil
+}
+
+// persistNotificationConfig marshals ncfg to notification.xml and
+// writes it to obj, under bucket's metadata prefix.
+func persistNotificationConfig(bucket string, ncfg *notificationConfig, obj ObjectLayer) error {
+	buf, err := xml.Marshal(ncfg)
+	if err != nil {
+		return err
+	}
+	path := bucketConfigPrefix + "/" + bucket + "/" + bucketNotificationConfig
+	return writeBucketMetadataObject(obj, path, buf)
+}
+
+// persistListenerConfig marshals lcfgs to listener.json and writes it
+// to obj, under bucket's metadata prefix.
+func persistListenerConfig(bucket string, lcfgs []listenerConfig, obj ObjectLayer) error {
+	buf, err := json.Marshal(lcfgs)
+	if err != nil {
+		return err
+	}
+	path := bucketConfigPrefix + "/" + bucket + "/" + bucketListenerConfig
+	return writeBucketMetadataObject(obj, path, buf)
+}
+
+// AddBucketListenerConfig appends lcfg to bucket's listener configs,
+// in-memory and on disk, and registers its internal target.
+func AddBucketListenerConfig(bucket string, lcfg *listenerConfig, obj ObjectLayer) error {
+	if lcfg == nil {
+		return errInvalidArgument
+	}
+
+	lcfgs := append(globalEventNotifier.GetBucketListenerConfig(bucket), *lcfg)
+	if err := persistListenerConfig(bucket, lcfgs, obj); err != nil {
+		return err
+	}
+
+	globalEventNotifier.setBucketListenerConfig(bucket, lcfgs)
+	globalEventNotifier.GetInternalTarget(lcfg.TopicConfig.TopicARN)
+	return nil
+}
+
+// RemoveBucketListenerConfig removes lcfg from bucket's listener
+// configs, in-memory and on disk. Unlike AddBucketListenerConfig it
+// does not return errors: a failure to persist the filtered list is
+// discarded, and the in-memory config is updated regardless.
+func RemoveBucketListenerConfig(bucket string, lcfg *listenerConfig, obj ObjectLayer) {
+	if lcfg == nil {
+		return
+	}
+
+	existing := globalEventNotifier.GetBucketListenerConfig(bucket)
+	filtered := make([]listenerConfig, 0, len(existing))
+	for _, l := range existing {
+		if l.TopicConfig.TopicARN != lcfg.TopicConfig.TopicARN || l.TargetServer != lcfg.TargetServer {
+			filtered = append(filtered, l)
+		}
+	}
+
+	_ = persistListenerConfig(bucket, filtered, obj)
+	globalEventNotifier.setBucketListenerConfig(bucket, filtered)
+}
+
+// newNotificationEvent builds the S3-event-shaped NotificationEvent for
+// a single eventData occurrence.
+func newNotificationEvent(e eventData) NotificationEvent {
+	region := serverConfig.GetRegion()
+
+	return NotificationEvent{
+		EventVersion: "2.0",
+		EventSource:  "aws:s3",
+		AwsRegion:    region,
+		EventTime:    timeToISO8601(time.Now().UTC()),
+		EventName:    e.Type.String(),
+		UserIdentity: identity{PrincipalID: "minio"},
+		RequestParameters: map[string]string{
+			"sourceIPAddress": e.ReqParams["sourceIPAddress"],
+		},
+		ResponseElements: map[string]string{},
+		S3: s3Meta{
+			SchemaVersion:   "1.0",
+			ConfigurationID: "Config",
+			Bucket: bucketMeta{
+				Name:          e.Bucket,
+				OwnerIdentity: identity{PrincipalID: "minio"},
+				ARN:           minioSqs + ":" + region + "::" + e.Bucket,
+			},
+			Object: objectMeta{
+				Key:       e.ObjInfo.Name,
+				Size:      e.ObjInfo.Size,
+				ETag:      e.ObjInfo.ETag,
+				Sequencer: fmt.Sprintf("%X", time.Now().UnixNano()),
+			},
+		},
+		Source: sourceInfo{
+			Host:      e.ReqParams["sourceIPAddress"],
+			UserAgent: e.ReqParams["userAgent"],
+		},
+	}
+}
+
+// eventNotify fires the notification for e against every queue config
+// matching its bucket and type, and fans it out to every internal
+// listener subscribed to a matching topic config.
+func eventNotify(e eventData) {
+	if globalEventNotifier == nil {
+		return
+	}
+
+	nEvent := newNotificationEvent(e)
+
+	if ncfg := globalEventNotifier.GetBucketNotificationConfig(e.Bucket); ncfg != nil {
+		for _, qcfg := range ncfg.QueueConfigs {
+			if !qcfg.ServiceConfig.match(e.Bucket, qcfg.QueueARN, e) {
+				continue
+			}
+			if globalEventNotifier.GetExternalTarget(qcfg.QueueARN) == nil {
+				continue
+			}
+			// Append to the on-disk queue before returning - this is
+			// the write-ahead step that makes delivery at-least-once:
+			// the event now survives a crash even if it happens before
+			// the target's background dispatcher ever picks it up.
+			if err := enqueueEvent(globalEventNotifier.obj, e.Bucket, qcfg.QueueARN, nEvent); err != nil {
+				errorIf(err, "Unable to enqueue event for target %s", qcfg.QueueARN)
+			}
+		}
+	}
+
+	for _, lcfg := range globalEventNotifier.GetBucketListenerConfig(e.Bucket) {
+		if !lcfg.TopicConfig.ServiceConfig.match(e.Bucket, lcfg.TopicConfig.TopicARN, e) {
+			continue
+		}
+		globalEventNotifier.GetInternalTarget(lcfg.TopicConfig.TopicARN).send([]NotificationEvent{nEvent})
+	}
+}
+
+// match returns true if e's type and object key satisfy sc's event
+// list and key filter. arn is the queue or topic ARN sc belongs to -
+// together with bucket it identifies the compiled-filter cache entry
+// to evaluate sc.Filter against, so repeated events don't pay to
+// recompile glob patterns, regexes or size bounds.
+func (sc ServiceConfig) match(bucket, arn string, e eventData) bool {
+	found := false
+	eventName := e.Type.String()
+	for _, ev := range sc.Event {
+		if ev == eventName || strings.HasSuffix(ev, ":*") && strings.HasPrefix(eventName, strings.TrimSuffix(ev, "*")) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	cf := getCompiledFilter(bucket, arn, sc.Filter.Key.FilterRules)
+	return cf.match(e.ObjInfo)
+}
@@ -0,0 +1,108 @@
+/*
+ * Minio Cloud Storage, (C) 2016, 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+
+	"github.com/streadway/amqp"
+)
+
+// amqpNotify - AMQP target configuration, persisted as part of the
+// server config under `notify.amqp.<id>`.
+type amqpNotify struct {
+	Enable       bool   `json:"enable"`
+	URL          string `json:"url"`
+	Exchange     string `json:"exchange"`
+	RoutingKey   string `json:"routingKey"`
+	ExchangeType string `json:"exchangeType"`
+	Mandatory    bool   `json:"mandatory"`
+	Immediate    bool   `json:"immediate"`
+	Durable      bool   `json:"durable"`
+	Internal     bool   `json:"internal"`
+	NoWait       bool   `json:"noWait"`
+	AutoDeleted  bool   `json:"autoDeleted"`
+}
+
+// amqpConn implements the `target` interface for an AMQP exchange.
+type amqpConn struct {
+	params amqpNotify
+}
+
+func dialAMQP(accountID string, amqpN amqpNotify) (*amqpConn, error) {
+	if !amqpN.Enable {
+		return nil, errNotifyNotEnabled
+	}
+	conn, err := amqp.Dial(amqpN.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	if err = ch.ExchangeDeclare(
+		amqpN.Exchange,
+		amqpN.ExchangeType,
+		amqpN.Durable,
+		amqpN.AutoDeleted,
+		amqpN.Internal,
+		amqpN.NoWait,
+		nil,
+	); err != nil {
+		return nil, err
+	}
+
+	return &amqpConn{params: amqpN}, nil
+}
+
+func (q *amqpConn) send(n NotificationEvent) error {
+	conn, err := amqp.Dial(q.params.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	return ch.Publish(
+		q.params.Exchange,
+		q.params.RoutingKey,
+		q.params.Mandatory,
+		q.params.Immediate,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+}
+
+func (q *amqpConn) Close() {
+}
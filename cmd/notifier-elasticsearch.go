@@ -0,0 +1,87 @@
+/*
+ * Minio Cloud Storage, (C) 2016, 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// elasticSearchNotify - ElasticSearch target configuration, persisted
+// as part of the server config under `notify.elasticsearch.<id>`.
+type elasticSearchNotify struct {
+	Enable bool   `json:"enable"`
+	URL    string `json:"url"`
+	Index  string `json:"index"`
+}
+
+// elasticSearchConn implements the `target` interface, indexing each
+// event as its own document, keyed by the object's key so creations
+// overwrite and deletions remove the corresponding document.
+type elasticSearchConn struct {
+	params elasticSearchNotify
+	client *elastic.Client
+}
+
+func dialElastic(accountID string, esNotify elasticSearchNotify) (*elasticSearchConn, error) {
+	if !esNotify.Enable {
+		return nil, errNotifyNotEnabled
+	}
+
+	client, err := elastic.NewClient(
+		elastic.SetURL(esNotify.URL),
+		elastic.SetSniff(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.IndexExists(esNotify.Index).Do(nil)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if _, err = client.CreateIndex(esNotify.Index).Do(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return &elasticSearchConn{params: esNotify, client: client}, nil
+}
+
+func (es *elasticSearchConn) send(n NotificationEvent) error {
+	client := es.client
+
+	if n.EventName == ObjectRemovedDelete.String() {
+		_, err := client.Delete().
+			Index(es.params.Index).
+			Type("event").
+			Id(n.S3.Object.Key).
+			Do(nil)
+		return err
+	}
+
+	_, err := client.Index().
+		Index(es.params.Index).
+		Type("event").
+		Id(n.S3.Object.Key).
+		BodyJson(n).
+		Do(nil)
+	return err
+}
+
+func (es *elasticSearchConn) Close() {
+}
@@ -0,0 +1,79 @@
+/*
+ * Minio Cloud Storage, (C) 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+
+	sarama "github.com/Shopify/sarama"
+)
+
+// kafkaNotify - Kafka target configuration, persisted as part of the
+// server config under `notify.kafka.<id>`.
+type kafkaNotify struct {
+	Enable  bool     `json:"enable"`
+	Brokers []string `json:"brokers"`
+	Topic   string   `json:"topic"`
+}
+
+// kafkaConn implements the `target` interface, producing each event to
+// `params.Topic`, keyed by the object name so ordering is preserved
+// per-object within a partition.
+type kafkaConn struct {
+	params   kafkaNotify
+	producer sarama.SyncProducer
+}
+
+func dialKafka(accountID string, k kafkaNotify) (*kafkaConn, error) {
+	if !k.Enable {
+		return nil, errNotifyNotEnabled
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 10
+	config.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(k.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaConn{params: k, producer: producer}, nil
+}
+
+func (k *kafkaConn) send(n NotificationEvent) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: k.params.Topic,
+		Key:   sarama.StringEncoder(n.S3.Object.Key),
+		Value: sarama.ByteEncoder(body),
+	}
+
+	_, _, err = k.producer.SendMessage(msg)
+	return err
+}
+
+func (k *kafkaConn) Close() {
+	if k.producer != nil {
+		k.producer.Close()
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Minio Cloud Storage, (C) 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"time"
+
+	nats "github.com/nats-io/go-nats"
+	stan "github.com/nats-io/go-nats-streaming"
+)
+
+// natsNotify - NATS target configuration, persisted as part of the
+// server config under `notify.nats.<id>`. When `Streaming.Enable` is
+// true, events are published through a NATS Streaming (durable)
+// connection instead of core NATS.
+type natsNotify struct {
+	Enable       bool   `json:"enable"`
+	Address      string `json:"address"`
+	Subject      string `json:"subject"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	Token        string `json:"token"`
+	Secure       bool   `json:"secure"`
+	PingInterval int64  `json:"pingInterval"`
+
+	Streaming struct {
+		Enable             bool   `json:"enable"`
+		ClusterID          string `json:"clusterID"`
+		ClientID           string `json:"clientID"`
+		Async              bool   `json:"async"`
+		MaxPubAcksInflight int    `json:"maxPubAcksInflight"`
+	} `json:"streaming"`
+}
+
+// natsConn implements the `target` interface for both core NATS and
+// NATS Streaming, reconnecting as needed.
+type natsConn struct {
+	params natsNotify
+	conn   *nats.Conn
+	sconn  stan.Conn
+}
+
+func dialNATS(accountID string, n natsNotify) (*natsConn, error) {
+	if !n.Enable {
+		return nil, errNotifyNotEnabled
+	}
+
+	connOpts := []nats.Option{
+		nats.ReconnectWait(1 * time.Second),
+		nats.MaxReconnects(-1),
+	}
+	if n.Username != "" && n.Password != "" {
+		connOpts = append(connOpts, nats.UserInfo(n.Username, n.Password))
+	}
+	if n.Token != "" {
+		connOpts = append(connOpts, nats.Token(n.Token))
+	}
+
+	conn, err := nats.Connect(n.Address, connOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.Streaming.Enable {
+		return &natsConn{params: n, conn: conn}, nil
+	}
+
+	sconn, err := stan.Connect(
+		n.Streaming.ClusterID,
+		n.Streaming.ClientID,
+		stan.NatsConn(conn),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &natsConn{params: n, conn: conn, sconn: sconn}, nil
+}
+
+func (q *natsConn) send(n NotificationEvent) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	if q.params.Streaming.Enable {
+		if q.params.Streaming.Async {
+			_, err = q.sconn.PublishAsync(q.params.Subject, body, nil)
+			return err
+		}
+		return q.sconn.Publish(q.params.Subject, body)
+	}
+
+	return q.conn.Publish(q.params.Subject, body)
+}
+
+func (q *natsConn) Close() {
+	if q.sconn != nil {
+		q.sconn.Close()
+	}
+	if q.conn != nil {
+		q.conn.Close()
+	}
+}
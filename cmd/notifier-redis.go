@@ -0,0 +1,78 @@
+/*
+ * Minio Cloud Storage, (C) 2016, 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+
+	redis "gopkg.in/redis.v5"
+)
+
+// redisNotify - Redis target configuration, persisted as part of the
+// server config under `notify.redis.<id>`.
+type redisNotify struct {
+	Enable   bool   `json:"enable"`
+	Addr     string `json:"address"`
+	Password string `json:"password"`
+	Key      string `json:"key"`
+}
+
+// redisConn implements the `target` interface, publishing events as
+// hash entries keyed by the object's key under `redisNotify.Key`.
+type redisConn struct {
+	params redisNotify
+	client *redis.Client
+}
+
+func dialRedis(accountID string, rNotify redisNotify) (*redisConn, error) {
+	if !rNotify.Enable {
+		return nil, errNotifyNotEnabled
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     rNotify.Addr,
+		Password: rNotify.Password,
+	})
+	defer client.Close()
+
+	if err := client.Ping().Err(); err != nil {
+		return nil, err
+	}
+
+	return &redisConn{params: rNotify}, nil
+}
+
+func (r *redisConn) send(n NotificationEvent) error {
+	client := redis.NewClient(&redis.Options{
+		Addr:     r.params.Addr,
+		Password: r.params.Password,
+	})
+	defer client.Close()
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	return client.HSet(r.params.Key, n.S3.Object.Key, string(body)).Err()
+}
+
+func (r *redisConn) Close() {
+	if r.client != nil {
+		r.client.Close()
+	}
+}
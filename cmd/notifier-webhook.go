@@ -0,0 +1,107 @@
+/*
+ * Minio Cloud Storage, (C) 2017 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 signature of the
+// request body, hex encoded, computed with the target's shared secret.
+const webhookSignatureHeader = "X-Minio-Signature"
+
+// webhookNotify - webhook target configuration, persisted as part of
+// the server config under `notify.webhook.<id>`.
+type webhookNotify struct {
+	Enable    bool   `json:"enable"`
+	Endpoint  string `json:"endpoint"`
+	SecretKey string `json:"secretKey"`
+}
+
+// webhookConn implements the `target` interface, POSTing the event
+// JSON to params.Endpoint with an HMAC-SHA256 signature header. A
+// single failed delivery is simply reported back to the caller -
+// retrying with backoff and keeping the event around until it's
+// eventually delivered is the on-disk eventQueue's job, the same as
+// for every other target, not something this target does on its own.
+type webhookConn struct {
+	params webhookNotify
+	client *http.Client
+}
+
+func dialWebhook(accountID string, w webhookNotify) (*webhookConn, error) {
+	if !w.Enable {
+		return nil, errNotifyNotEnabled
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	req, err := http.NewRequest(http.MethodHead, w.Endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return &webhookConn{params: w, client: client}, nil
+}
+
+// sign returns the hex-encoded HMAC-SHA256 of body, keyed by the
+// target's shared secret.
+func (w *webhookConn) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.params.SecretKey))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhookConn) send(n NotificationEvent) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.params.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, w.sign(body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook target returned status %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *webhookConn) Close() {
+}